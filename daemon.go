@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mauroartizzu/tf-go-tunnel-opensearch/internal/control"
+	"github.com/mauroartizzu/tf-go-tunnel-opensearch/internal/proxy"
+	"github.com/spf13/cobra"
+)
+
+// daemonSentinelEnv marks a re-exec'd process as the detached child in
+// control.Daemonize.
+const daemonSentinelEnv = "OPENSEARCH_TUNNEL_DAEMON"
+
+// runDir returns the directory holding pidfiles and control sockets
+// for backgrounded tunnels, e.g. ~/.config/tf/run.
+func runDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".", ".config", "tf", "run")
+	}
+	return filepath.Join(home, ".config", "tf", "run")
+}
+
+func pidFilePath(environment string) string {
+	return filepath.Join(runDir(), environment+".pid")
+}
+
+func addrFilePath(environment string) string {
+	return filepath.Join(runDir(), environment+".addr")
+}
+
+// newStartCmd builds "start", which establishes the tunnel the same
+// way the root command does, optionally detaching into the background
+// and exposing a control socket for stop/status/list.
+func newStartCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "start",
+		Short: "Start the OpenSearch tunnel, optionally in the background",
+		Run:   runStart,
+	}
+	cmd.Flags().StringP("environment", "e", "", "Environment to connect to (e.g., staging, production)")
+	cmd.Flags().String("bastion", "", "Bastion host (e.g., ubuntu@12.34.56.78)")
+	cmd.Flags().String("key", "", "SSH key path (e.g., ~/.ssh/key.pem)")
+	cmd.Flags().Bool("insecure-host-key", false, "Skip known_hosts verification (previous default behavior)")
+	cmd.Flags().Bool("detach", false, "Run in the background and return immediately")
+	return cmd
+}
+
+func newStopCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stop",
+		Short: "Stop a backgrounded tunnel",
+		Run:   runStop,
+	}
+	cmd.Flags().StringP("environment", "e", "", "Environment whose tunnel to stop")
+	return cmd
+}
+
+func newStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show whether a tunnel's environment is running",
+		Run:   runStatus,
+	}
+	cmd.Flags().StringP("environment", "e", "", "Environment whose tunnel to check")
+	return cmd
+}
+
+func newListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List all backgrounded tunnels",
+		Run:   runList,
+	}
+}
+
+func runStart(cmd *cobra.Command, args []string) {
+	config, err := getConfig(cmd)
+	if err != nil {
+		log.Fatalf("Error with configuration: %v", err)
+	}
+
+	environment := getEnvironment(cmd, config)
+	envConfig, exists := config.Environments[environment]
+	if !exists {
+		log.Fatalf("Invalid environment: %s. Available environments: %v",
+			environment, getEnvironmentKeys(config.Environments))
+	}
+
+	detach, _ := cmd.Flags().GetBool("detach")
+	if detach {
+		isChild, err := control.Daemonize(daemonSentinelEnv)
+		if err != nil {
+			log.Fatalf("Failed to background the tunnel: %v", err)
+		}
+		if !isChild {
+			fmt.Printf("Started %s tunnel in background. Use \"opensearch-tunnel status -e %s\" to check on it.\n", environment, environment)
+			return
+		}
+	}
+
+	if err := os.MkdirAll(runDir(), 0700); err != nil {
+		log.Fatalf("Failed to create run directory: %v", err)
+	}
+	if err := control.WritePIDFile(pidFilePath(environment)); err != nil {
+		log.Fatalf("Failed to write pidfile: %v", err)
+	}
+	defer control.RemovePIDFile(pidFilePath(environment))
+	defer os.Remove(addrFilePath(environment))
+
+	bastionHost := config.BastionHost
+	keyPath := config.KeyPath
+	if envConfig.Auth.BastionHost != "" {
+		bastionHost = envConfig.Auth.BastionHost
+	}
+	if envConfig.Auth.KeyPath != "" {
+		keyPath = envConfig.Auth.KeyPath
+	}
+	insecureHostKey, _ := cmd.Flags().GetBool("insecure-host-key")
+	insecureHostKey = insecureHostKey || envConfig.Auth.InsecureHostKey
+
+	username, hostname, proxyJump, err := resolveBastion(bastionHost, &keyPath)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	sshConfig, err := prepareSSHConfig(keyPath, username, insecureHostKey)
+	if err != nil {
+		log.Fatalf("Failed to prepare SSH configuration: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger, metrics := setupTelemetry(cmd, ctx)
+
+	supervisor, err := dialSupervisor(cmd, hostname, proxyJump, insecureHostKey, sshConfig, logger, metrics)
+	if err != nil {
+		log.Fatalf("Failed to dial SSH server: %v", err)
+	}
+	defer supervisor.Close()
+
+	injectHeaders, err := headerInjectorFor(envConfig.Auth)
+	if err != nil {
+		log.Fatalf("Failed to configure OpenSearch auth: %v", err)
+	}
+
+	proxyServer, err := proxy.New(proxy.Options{
+		ListenAddr: fmt.Sprintf("localhost:%d", localPort),
+		TargetHost: envConfig.OpenSearchHost,
+		TargetPort: remotePort,
+		Dial: func(network, addr string) (net.Conn, error) {
+			return supervisor.Client().Dial(network, addr)
+		},
+		CertDir:       certDir(),
+		InjectHeaders: injectHeaders,
+		Logger:        logger,
+		Metrics:       metrics,
+		Env:           environment,
+	})
+	if err != nil {
+		log.Fatalf("Failed to start local HTTPS proxy: %v", err)
+	}
+	go func() {
+		if err := proxyServer.ListenAndServeTLS(); err != nil {
+			log.Printf("Proxy server error: %v", err)
+		}
+	}()
+
+	stopped := make(chan struct{})
+	listener, addr, err := control.ListenControl(runDir(), environment)
+	if err != nil {
+		log.Fatalf("Failed to start control socket: %v", err)
+	}
+	if err := os.WriteFile(addrFilePath(environment), []byte(addr), 0644); err != nil {
+		log.Fatalf("Failed to persist control address: %v", err)
+	}
+
+	controlServer := control.NewServer(listener, func(c string) string {
+		switch c {
+		case control.CmdStatus:
+			return fmt.Sprintf("running pid=%d env=%s", os.Getpid(), environment)
+		case control.CmdStop:
+			close(stopped)
+			return "stopping"
+		default:
+			return "unknown command"
+		}
+	})
+	go controlServer.Serve()
+
+	fmt.Printf("Tunnel for %s listening on localhost:%d\n", environment, localPort)
+	<-stopped
+
+	controlServer.Close()
+	proxyServer.Close()
+}
+
+func runStop(cmd *cobra.Command, args []string) {
+	environment := requireEnvironmentFlag(cmd)
+	reply, err := sendControlCommand(environment, control.CmdStop)
+	if err != nil {
+		log.Fatalf("Failed to stop %s: %v", environment, err)
+	}
+	fmt.Println(reply)
+}
+
+func runStatus(cmd *cobra.Command, args []string) {
+	environment := requireEnvironmentFlag(cmd)
+	reply, err := sendControlCommand(environment, control.CmdStatus)
+	if err != nil {
+		fmt.Println(staleStatus(environment, err))
+		return
+	}
+	fmt.Printf("%s: %s\n", environment, reply)
+}
+
+func runList(cmd *cobra.Command, args []string) {
+	entries, err := os.ReadDir(runDir())
+	if os.IsNotExist(err) {
+		fmt.Println("No backgrounded tunnels.")
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to read run directory: %v", err)
+	}
+
+	found := false
+	for _, entry := range entries {
+		environment, ok := strings.CutSuffix(entry.Name(), ".pid")
+		if !ok {
+			continue
+		}
+		found = true
+		reply, err := sendControlCommand(environment, control.CmdStatus)
+		if err != nil {
+			fmt.Println(staleStatus(environment, err))
+			continue
+		}
+		fmt.Printf("%s: %s\n", environment, reply)
+	}
+	if !found {
+		fmt.Println("No backgrounded tunnels.")
+	}
+}
+
+// staleStatus describes why environment's control socket couldn't be
+// reached. If its pidfile names a process that's no longer running, it
+// reports the stale pidfile instead of just the dial error and cleans
+// up the pidfile and addr file so a future "start" doesn't trip over
+// them.
+func staleStatus(environment string, dialErr error) string {
+	pid, err := control.ReadPIDFile(pidFilePath(environment))
+	if err != nil || control.ProcessAlive(pid) {
+		return fmt.Sprintf("%s: not running (%v)", environment, dialErr)
+	}
+
+	control.RemovePIDFile(pidFilePath(environment))
+	os.Remove(addrFilePath(environment))
+	return fmt.Sprintf("%s: not running (stale pidfile for pid %d removed)", environment, pid)
+}
+
+func requireEnvironmentFlag(cmd *cobra.Command) string {
+	environment, _ := cmd.Flags().GetString("environment")
+	if environment == "" {
+		log.Fatalf("--environment is required")
+	}
+	return environment
+}
+
+func sendControlCommand(environment, cmd string) (string, error) {
+	addr, err := os.ReadFile(addrFilePath(environment))
+	if err != nil {
+		return "", fmt.Errorf("no running tunnel found for %s: %w", environment, err)
+	}
+	network, dialAddr := control.DialControl(string(addr))
+	return control.SendCommand(network, dialAddr, cmd)
+}
@@ -0,0 +1,131 @@
+// Package auth builds SSH authentication methods and host key
+// verification for the tunnel, layering an ssh-agent, encrypted/plain
+// key files, and interactive password prompts on top of what
+// golang.org/x/crypto/ssh provides directly.
+package auth
+
+import (
+	"bufio"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+)
+
+// Methods builds the ordered list of ssh.AuthMethod to offer the
+// server: ssh-agent first (if SSH_AUTH_SOCK is set and reachable),
+// then the configured private key (prompting for a passphrase if it's
+// encrypted), then an interactive password prompt as a last resort.
+func Methods(keyPath string) []ssh.AuthMethod {
+	var methods []ssh.AuthMethod
+
+	if am, err := agentAuthMethod(); err != nil {
+		fmt.Fprintf(os.Stderr, "ssh-agent unavailable, skipping: %v\n", err)
+	} else if am != nil {
+		methods = append(methods, am)
+	}
+
+	if keyPath != "" {
+		if am, err := keyFileAuthMethod(keyPath); err != nil {
+			fmt.Fprintf(os.Stderr, "key file %s unavailable, skipping: %v\n", keyPath, err)
+		} else {
+			methods = append(methods, am)
+		}
+	}
+
+	methods = append(methods, ssh.PasswordCallback(passwordPrompt))
+
+	return methods
+}
+
+// agentAuthMethod connects to the ssh-agent at SSH_AUTH_SOCK, if any.
+func agentAuthMethod() (ssh.AuthMethod, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, nil
+	}
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, fmt.Errorf("dialing SSH_AUTH_SOCK: %w", err)
+	}
+
+	client := agent.NewClient(conn)
+	return ssh.PublicKeysCallback(client.Signers), nil
+}
+
+// keyFileAuthMethod reads and parses a private key file, prompting for
+// a passphrase if it's encrypted.
+func keyFileAuthMethod(keyPath string) (ssh.AuthMethod, error) {
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading private key: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if err == nil {
+		return ssh.PublicKeys(signer), nil
+	}
+
+	var passphraseErr *ssh.PassphraseMissingError
+	if !errors.As(err, &passphraseErr) {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		passphrase, perr := passphrasePrompt(keyPath)
+		if perr != nil {
+			return nil, perr
+		}
+
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+		if err == nil {
+			return ssh.PublicKeys(signer), nil
+		}
+
+		if !errors.Is(err, x509.IncorrectPasswordError) {
+			return nil, fmt.Errorf("parsing private key with passphrase: %w", err)
+		}
+		fmt.Fprintln(os.Stderr, "Incorrect passphrase, try again.")
+	}
+
+	return nil, fmt.Errorf("too many incorrect passphrase attempts for %s", keyPath)
+}
+
+func passphrasePrompt(keyPath string) (string, error) {
+	fmt.Fprintf(os.Stderr, "Enter passphrase for %s: ", keyPath)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase: %w", err)
+	}
+	return string(passphrase), nil
+}
+
+func passwordPrompt() (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	return string(password), nil
+}
+
+// promptLine reads a single line of input from stdin, trimming the
+// trailing newline. Used by the host key TOFU prompt.
+func promptLine(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	reader := bufio.NewReader(os.Stdin)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyCallback builds a host key callback for ssh.ClientConfig. When
+// insecure is true it preserves the tool's previous behaviour of
+// accepting any host key. Otherwise it verifies against
+// knownHostsPath, prompting to trust-on-first-use and append unknown
+// host keys rather than failing closed like the stock knownhosts
+// callback does.
+func HostKeyCallback(knownHostsPath string, insecure bool) (ssh.HostKeyCallback, error) {
+	if insecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	if err := ensureKnownHostsFile(knownHostsPath); err != nil {
+		return nil, err
+	}
+
+	callback, err := knownhosts.New(knownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading known_hosts from %s: %w", knownHostsPath, err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		var keyErr *knownhosts.KeyError
+		if !isKeyError(err, &keyErr) || len(keyErr.Want) != 0 {
+			// Either a non-knownhosts error, or the host is known but
+			// the key changed: refuse, don't silently TOFU over a
+			// potential MITM.
+			return err
+		}
+
+		if !confirmTOFU(hostname, key) {
+			return fmt.Errorf("host key for %s rejected by user", hostname)
+		}
+
+		return appendKnownHost(knownHostsPath, hostname, key)
+	}, nil
+}
+
+func isKeyError(err error, target **knownhosts.KeyError) bool {
+	keyErr, ok := err.(*knownhosts.KeyError)
+	if !ok {
+		return false
+	}
+	*target = keyErr
+	return true
+}
+
+func confirmTOFU(hostname string, key ssh.PublicKey) bool {
+	fmt.Fprintf(os.Stderr, "The authenticity of host %q can't be established.\n", hostname)
+	fmt.Fprintf(os.Stderr, "%s key fingerprint is %s.\n", key.Type(), ssh.FingerprintSHA256(key))
+	answer, err := promptLine("Are you sure you want to continue connecting (yes/no)? ")
+	if err != nil {
+		return false
+	}
+	return answer == "yes\n" || answer == "yes"
+}
+
+func appendKnownHost(knownHostsPath, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(knownHostsPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("opening known_hosts: %w", err)
+	}
+	defer f.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		return fmt.Errorf("appending to known_hosts: %w", err)
+	}
+	return nil
+}
+
+func ensureKnownHostsFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("creating known_hosts directory: %w", err)
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return os.WriteFile(path, nil, 0600)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+// ResolvedHost is the set of fields ~/.ssh/config can override for a
+// given Host alias.
+type ResolvedHost struct {
+	User         string
+	HostName     string
+	IdentityFile string
+	ProxyJump    string
+}
+
+// ResolveHost looks up alias in ~/.ssh/config (if present) and returns
+// the User/HostName/IdentityFile/ProxyJump it resolves to. Fields with
+// no matching entry are returned empty so callers can fall back to
+// their own defaults.
+func ResolveHost(alias string) (ResolvedHost, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ResolvedHost{}, fmt.Errorf("finding home directory: %w", err)
+	}
+
+	path := filepath.Join(home, ".ssh", "config")
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ResolvedHost{}, nil
+	}
+	if err != nil {
+		return ResolvedHost{}, fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	cfg, err := ssh_config.Decode(f)
+	if err != nil {
+		return ResolvedHost{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	get := func(key string) string {
+		value, _ := cfg.Get(alias, key)
+		return value
+	}
+
+	return ResolvedHost{
+		User:         get("User"),
+		HostName:     get("HostName"),
+		IdentityFile: expandTilde(get("IdentityFile")),
+		ProxyJump:    get("ProxyJump"),
+	}, nil
+}
+
+func expandTilde(path string) string {
+	if path == "" || path[0] != '~' {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
@@ -0,0 +1,90 @@
+//go:build !windows
+
+package control
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// Daemonize re-execs the current process detached from the controlling
+// terminal, the same way godaemon-style tools do it: the parent starts
+// a copy of itself with sentinelEnv set and syscall.Setsid, then
+// returns isChild=false so the caller can exit; the child observes
+// sentinelEnv, ignores SIGHUP, detaches stdio, and returns
+// isChild=true to keep running as the daemon.
+func Daemonize(sentinelEnv string) (isChild bool, err error) {
+	if os.Getenv(sentinelEnv) != "" {
+		signal.Ignore(syscall.SIGHUP)
+
+		devnull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+		if err != nil {
+			return true, fmt.Errorf("opening %s: %w", os.DevNull, err)
+		}
+		os.Stdin = devnull
+		os.Stdout = devnull
+		os.Stderr = devnull
+
+		return true, nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return false, fmt.Errorf("finding executable: %w", err)
+	}
+
+	devnull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return false, fmt.Errorf("opening %s: %w", os.DevNull, err)
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), sentinelEnv+"=1")
+	cmd.Stdin = devnull
+	cmd.Stdout = devnull
+	cmd.Stderr = devnull
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := cmd.Start(); err != nil {
+		return false, fmt.Errorf("re-exec for daemonize: %w", err)
+	}
+
+	return false, nil
+}
+
+// ListenControl binds a Unix domain socket at <runDir>/<name>.sock,
+// returning the listener and the address to persist for clients.
+func ListenControl(runDir, name string) (net.Listener, string, error) {
+	if err := os.MkdirAll(runDir, 0700); err != nil {
+		return nil, "", fmt.Errorf("creating run directory: %w", err)
+	}
+	sockPath := filepath.Join(runDir, name+".sock")
+	os.Remove(sockPath) // stale socket from a prior unclean shutdown
+
+	listener, err := net.Listen("unix", sockPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("listening on control socket: %w", err)
+	}
+	return listener, sockPath, nil
+}
+
+// DialControl connects to a control address previously returned by
+// ListenControl.
+func DialControl(addr string) (network, dialAddr string) {
+	return "unix", addr
+}
+
+// ProcessAlive reports whether pid refers to a currently running
+// process, by sending it the null signal.
+func ProcessAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,90 @@
+//go:build windows
+
+package control
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	createNewProcessGroup = 0x00000200
+	detachedProcess       = 0x00000008
+)
+
+// Daemonize re-execs the current process as a detached process group
+// on Windows, where fork/setsid aren't available. The child observes
+// sentinelEnv and returns isChild=true; the parent starts the detached
+// copy and returns isChild=false so the caller can exit.
+func Daemonize(sentinelEnv string) (isChild bool, err error) {
+	if os.Getenv(sentinelEnv) != "" {
+		return true, nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return false, fmt.Errorf("finding executable: %w", err)
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), sentinelEnv+"=1")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: createNewProcessGroup | detachedProcess,
+	}
+
+	if err := cmd.Start(); err != nil {
+		return false, fmt.Errorf("launching detached process: %w", err)
+	}
+
+	return false, nil
+}
+
+// ListenControl binds a TCP loopback port, since Windows has no Unix
+// domain sockets in older releases. The chosen "host:port" address is
+// written to runDir/<name>.port and also returned, for the caller to
+// persist where clients look for it.
+func ListenControl(runDir, name string) (net.Listener, string, error) {
+	if err := os.MkdirAll(runDir, 0700); err != nil {
+		return nil, "", fmt.Errorf("creating run directory: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", fmt.Errorf("listening on control port: %w", err)
+	}
+
+	addr := listener.Addr().String()
+	portFile := filepath.Join(runDir, name+".port")
+	if err := os.WriteFile(portFile, []byte(addr), 0644); err != nil {
+		listener.Close()
+		return nil, "", fmt.Errorf("writing port file: %w", err)
+	}
+
+	return listener, addr, nil
+}
+
+// DialControl connects to a control address previously returned by
+// ListenControl (a "host:port" string).
+func DialControl(addr string) (network, dialAddr string) {
+	return "tcp", addr
+}
+
+// processQueryLimitedInformation is PROCESS_QUERY_LIMITED_INFORMATION,
+// the minimal access right that lets us merely check a process exists.
+const processQueryLimitedInformation = 0x1000
+
+// ProcessAlive reports whether pid refers to a currently running
+// process. Unlike on Unix, (*os.Process).Signal only supports
+// os.Kill on Windows, so we open a handle instead of signaling.
+func ProcessAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	syscall.CloseHandle(handle)
+	return true
+}
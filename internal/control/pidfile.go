@@ -0,0 +1,36 @@
+package control
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WritePIDFile writes the current process's PID to path.
+func WritePIDFile(path string) error {
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0644)
+}
+
+// ReadPIDFile reads and parses the PID previously written by
+// WritePIDFile.
+func ReadPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("reading pidfile: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("parsing pidfile: %w", err)
+	}
+	return pid, nil
+}
+
+// RemovePIDFile removes a pidfile, ignoring a not-exist error.
+func RemovePIDFile(path string) error {
+	err := os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
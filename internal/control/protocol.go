@@ -0,0 +1,86 @@
+// Package control implements the small line-based protocol used to
+// talk to a backgrounded tunnel process: a Unix domain socket (TCP
+// loopback on Windows) accepting single-line commands and replying
+// with a single line.
+package control
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Commands understood by the control protocol.
+const (
+	CmdStatus = "STATUS"
+	CmdStop   = "STOP"
+)
+
+// Handler answers a single control command, returning the line to send
+// back to the client.
+type Handler func(cmd string) string
+
+// Server accepts connections on listener and dispatches each line it
+// receives to handle, replying with handle's return value.
+type Server struct {
+	listener net.Listener
+	handle   Handler
+}
+
+// NewServer wraps an already-bound listener (a Unix socket or loopback
+// TCP port) with the control protocol.
+func NewServer(listener net.Listener, handle Handler) *Server {
+	return &Server{listener: listener, handle: handle}
+}
+
+// Serve accepts connections until the listener is closed.
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		return
+	}
+	cmd := strings.TrimSpace(scanner.Text())
+	reply := s.handle(cmd)
+	fmt.Fprintln(conn, reply)
+}
+
+// Close stops accepting new connections.
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// SendCommand dials addr (a Unix socket path or "host:port") and sends
+// a single command, returning the single-line reply.
+func SendCommand(network, addr, cmd string) (string, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return "", fmt.Errorf("dialing control socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprintln(conn, cmd); err != nil {
+		return "", fmt.Errorf("sending command: %w", err)
+	}
+
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", fmt.Errorf("reading reply: %w", err)
+		}
+		return "", fmt.Errorf("no reply from control socket")
+	}
+	return scanner.Text(), nil
+}
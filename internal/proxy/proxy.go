@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// HeaderInjector mutates an outbound request before it's sent to the
+// remote host, e.g. to add an Authorization header.
+type HeaderInjector func(req *http.Request)
+
+// Metrics is the subset of telemetry.Metrics the proxy package needs.
+// A nil Metrics on Options means no metrics are recorded.
+type Metrics interface {
+	ConnectionOpened(env string)
+	ConnectionClosed(env string)
+	BytesTransferred(dir string, n int64)
+	DialError(env string)
+}
+
+// Options configures a Server.
+type Options struct {
+	// ListenAddr is the local address to terminate TLS on, e.g.
+	// "localhost:5602".
+	ListenAddr string
+	// TargetHost/TargetPort is the remote host the proxy forwards to.
+	TargetHost string
+	TargetPort int
+	// Dial is used to reach TargetHost, normally bound to an
+	// *ssh.Client's Dial method so traffic is tunneled over SSH.
+	Dial func(network, addr string) (net.Conn, error)
+	// CertDir is where the local CA persists, e.g. ~/.config/tf/certs.
+	CertDir string
+	// InjectHeaders, if set, is called on every proxied request.
+	InjectHeaders HeaderInjector
+	// Logger receives one structured log entry per request, tagged
+	// with a request UUID so a slow request can be traced end-to-end.
+	// Defaults to slog.Default() if nil.
+	Logger *slog.Logger
+	// Metrics, if set, is updated with connection counts and bytes
+	// transferred for every proxied request.
+	Metrics Metrics
+	// Env labels metrics recorded through Metrics (e.g. "staging").
+	Env string
+}
+
+// Server is a local HTTPS reverse proxy that terminates TLS with a
+// locally-trusted CA and forwards requests to Options.TargetHost
+// through Options.Dial.
+type Server struct {
+	http *http.Server
+	ca   *CA
+}
+
+// New builds a Server from opts, loading or creating the local CA and
+// issuing a leaf certificate for the listener.
+func New(opts Options) (*Server, error) {
+	ca, err := LoadOrCreateCA(opts.CertDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading CA: %w", err)
+	}
+
+	host, _, err := net.SplitHostPort(opts.ListenAddr)
+	if err != nil {
+		host = opts.ListenAddr
+	}
+	leaf, err := ca.IssueLeafCert(host)
+	if err != nil {
+		return nil, fmt.Errorf("issuing leaf certificate: %w", err)
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	target := &url.URL{Scheme: "https", Host: fmt.Sprintf("%s:%d", opts.TargetHost, opts.TargetPort)}
+	reverseProxy := httputil.NewSingleHostReverseProxy(target)
+	reverseProxy.Transport = &instrumentedTransport{
+		base: &http.Transport{
+			DialContext: func(_ context.Context, network, addr string) (net.Conn, error) {
+				return opts.Dial(network, addr)
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // remote presents its own self-signed cert; we only vouch for the local hop
+		},
+		metrics: opts.Metrics,
+		env:     opts.Env,
+	}
+
+	originalDirector := reverseProxy.Director
+	reverseProxy.Director = func(req *http.Request) {
+		originalDirector(req)
+		if opts.InjectHeaders != nil {
+			opts.InjectHeaders(req)
+		}
+	}
+
+	handler := &loggingHandler{
+		next:    reverseProxy,
+		logger:  logger,
+		metrics: opts.Metrics,
+		env:     opts.Env,
+	}
+
+	httpServer := &http.Server{
+		Addr:    opts.ListenAddr,
+		Handler: handler,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{leaf},
+		},
+	}
+
+	return &Server{http: httpServer, ca: ca}, nil
+}
+
+// loggingHandler logs each request with a request UUID so it can be
+// traced end-to-end, and records connection counts in Metrics.
+type loggingHandler struct {
+	next    http.Handler
+	logger  *slog.Logger
+	metrics Metrics
+	env     string
+}
+
+func (h *loggingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := uuid.NewString()
+	logger := h.logger.With("request_id", requestID, "method", r.Method, "path", r.URL.Path)
+
+	if h.metrics != nil {
+		h.metrics.ConnectionOpened(h.env)
+		defer h.metrics.ConnectionClosed(h.env)
+	}
+
+	logger.Debug("proxying request")
+	h.next.ServeHTTP(w, r)
+}
+
+// instrumentedTransport wraps http.Transport to count request/response
+// bytes transferred, and to record dial failures.
+type instrumentedTransport struct {
+	base    *http.Transport
+	metrics Metrics
+	env     string
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && t.metrics != nil {
+		req.Body = &countingReadCloser{rc: req.Body, dir: "out", metrics: t.metrics}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		if t.metrics != nil {
+			t.metrics.DialError(t.env)
+		}
+		return nil, err
+	}
+
+	if resp.Body != nil && t.metrics != nil {
+		resp.Body = &countingReadCloser{rc: resp.Body, dir: "in", metrics: t.metrics}
+	}
+	return resp, nil
+}
+
+type countingReadCloser struct {
+	rc interface {
+		Read([]byte) (int, error)
+		Close() error
+	}
+	dir     string
+	metrics Metrics
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.rc.Read(p)
+	if n > 0 {
+		c.metrics.BytesTransferred(c.dir, int64(n))
+	}
+	return n, err
+}
+
+func (c *countingReadCloser) Close() error {
+	return c.rc.Close()
+}
+
+// ListenAndServeTLS starts serving until the server is closed. It
+// blocks, so callers typically run it in a goroutine.
+func (s *Server) ListenAndServeTLS() error {
+	err := s.http.ListenAndServeTLS("", "")
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Close shuts down the HTTPS listener.
+func (s *Server) Close() error {
+	return s.http.Close()
+}
+
+// CACertPEM returns the PEM-encoded CA certificate, the same bytes the
+// print-ca command prints.
+func (s *Server) CACertPEM() []byte {
+	return s.ca.CertPEM
+}
@@ -0,0 +1,202 @@
+// Package sshconn supervises a single SSH connection, sending periodic
+// keepalives and transparently reconnecting with exponential backoff
+// when the transport fails, so a transient bastion restart doesn't
+// kill every in-flight forward.
+package sshconn
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Metrics is the subset of telemetry.Metrics the supervisor needs.
+type Metrics interface {
+	SSHReconnected()
+}
+
+const (
+	defaultKeepaliveInterval    = 30 * time.Second
+	defaultMaxReconnectAttempts = 0 // unlimited
+	minBackoff                  = 500 * time.Millisecond
+	maxBackoff                  = 30 * time.Second
+)
+
+// DialFunc establishes a fresh SSH connection to the bastion. It's
+// called both for the initial connection and every reconnect attempt.
+type DialFunc func() (*ssh.Client, error)
+
+// Supervisor owns the current *ssh.Client and keeps it alive,
+// redialing with capped exponential backoff on failure. Callers that
+// need the live connection (e.g. to Dial or Listen) should call
+// Client() on every use rather than caching the result, since it
+// changes across reconnects.
+type Supervisor struct {
+	dial                 DialFunc
+	keepaliveInterval    time.Duration
+	maxReconnectAttempts int
+	logger               *slog.Logger
+	metrics              Metrics
+	onReconnect          func(*ssh.Client)
+
+	mu     sync.RWMutex
+	client *ssh.Client
+	closed bool
+	done   chan struct{}
+}
+
+// Option configures optional Supervisor behavior.
+type Option func(*Supervisor)
+
+// WithKeepaliveInterval overrides the default 30s keepalive period.
+func WithKeepaliveInterval(d time.Duration) Option {
+	return func(s *Supervisor) { s.keepaliveInterval = d }
+}
+
+// WithMaxReconnectAttempts caps how many times Supervisor retries a
+// single reconnect episode before giving up. 0 (the default) means
+// unlimited. Once the cap is hit the Supervisor closes itself rather
+// than retrying again on the next keepalive failure; Client() keeps
+// returning the last (dead) client afterward.
+func WithMaxReconnectAttempts(n int) Option {
+	return func(s *Supervisor) { s.maxReconnectAttempts = n }
+}
+
+// WithLogger attaches a structured logger. Defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Supervisor) { s.logger = logger }
+}
+
+// WithMetrics attaches a Metrics sink incremented on every successful
+// reconnect.
+func WithMetrics(metrics Metrics) Option {
+	return func(s *Supervisor) { s.metrics = metrics }
+}
+
+// WithOnReconnect registers a callback invoked with the new client
+// after each successful reconnect, so callers (e.g. tunnel.Manager)
+// can re-establish anything bound to the old connection, such as
+// remote listeners.
+func WithOnReconnect(fn func(*ssh.Client)) Option {
+	return func(s *Supervisor) { s.onReconnect = fn }
+}
+
+// NewSupervisor dials once via dial and starts the keepalive/reconnect
+// loop in the background.
+func NewSupervisor(dial DialFunc, opts ...Option) (*Supervisor, error) {
+	client, err := dial()
+	if err != nil {
+		return nil, fmt.Errorf("initial SSH dial: %w", err)
+	}
+
+	s := &Supervisor{
+		dial:                 dial,
+		keepaliveInterval:    defaultKeepaliveInterval,
+		maxReconnectAttempts: defaultMaxReconnectAttempts,
+		logger:               slog.Default(),
+		client:               client,
+		done:                 make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	go s.monitor()
+	return s, nil
+}
+
+// Client returns the current live SSH client. Callers must not cache
+// the result across reconnects; call Client() again for each new dial.
+func (s *Supervisor) Client() *ssh.Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.client
+}
+
+func (s *Supervisor) monitor() {
+	ticker := time.NewTicker(s.keepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			client := s.Client()
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			if err != nil {
+				s.logger.Warn("ssh keepalive failed, reconnecting", "error", err)
+				s.reconnect()
+			}
+		}
+	}
+}
+
+// reconnect redials with capped exponential backoff (500ms to 30s,
+// jittered) until it succeeds or MaxReconnectAttempts is exhausted, in
+// which case it closes the Supervisor so monitor doesn't keep firing
+// reconnect attempts in bursts on every subsequent keepalive failure.
+func (s *Supervisor) reconnect() {
+	backoff := minBackoff
+
+	for attempt := 1; s.maxReconnectAttempts == 0 || attempt <= s.maxReconnectAttempts; attempt++ {
+		select {
+		case <-s.done:
+			return
+		case <-time.After(jitter(backoff)):
+		}
+
+		client, err := s.dial()
+		if err != nil {
+			s.logger.Warn("ssh reconnect attempt failed", "attempt", attempt, "error", err)
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+
+		s.mu.Lock()
+		old := s.client
+		s.client = client
+		s.mu.Unlock()
+		old.Close()
+
+		s.logger.Info("ssh reconnected", "attempt", attempt)
+		if s.metrics != nil {
+			s.metrics.SSHReconnected()
+		}
+		if s.onReconnect != nil {
+			s.onReconnect(client)
+		}
+		return
+	}
+
+	s.logger.Error("giving up on ssh reconnect, closing supervisor", "max_attempts", s.maxReconnectAttempts)
+	s.Close()
+}
+
+// jitter returns d plus up to 20% random jitter, to avoid a thundering
+// herd of reconnects all retrying in lockstep.
+func jitter(d time.Duration) time.Duration {
+	return d + time.Duration(rand.Int63n(int64(d)/5+1))
+}
+
+// Close stops the keepalive loop and closes the current SSH client.
+func (s *Supervisor) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	client := s.client
+	s.mu.Unlock()
+
+	return client.Close()
+}
@@ -0,0 +1,38 @@
+package sshconn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitterWithinBounds(t *testing.T) {
+	d := 500 * time.Millisecond
+	for i := 0; i < 1000; i++ {
+		got := jitter(d)
+		if got < d {
+			t.Fatalf("jitter(%s) = %s, want >= %s", d, got, d)
+		}
+		if max := d + d/5; got > max {
+			t.Fatalf("jitter(%s) = %s, want <= %s", d, got, max)
+		}
+	}
+}
+
+func TestJitterZero(t *testing.T) {
+	if got := jitter(0); got != 0 {
+		t.Fatalf("jitter(0) = %s, want 0", got)
+	}
+}
+
+func TestBackoffDoublesUpToCap(t *testing.T) {
+	backoff := minBackoff
+	for i := 0; i < 10; i++ {
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	if backoff != maxBackoff {
+		t.Fatalf("backoff after repeated doubling = %s, want capped at %s", backoff, maxBackoff)
+	}
+}
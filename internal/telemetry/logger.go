@@ -0,0 +1,49 @@
+// Package telemetry configures structured logging and Prometheus
+// metrics for the tunnel, replacing the ad-hoc log.Printf/fmt.Println
+// calls that previously scattered output across the codebase.
+package telemetry
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// NewLogger builds a slog.Logger writing to stderr in either "text" or
+// "json" format at the given level ("debug", "info", "warn", "error").
+func NewLogger(level, format string) (*slog.Logger, error) {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q: expected \"text\" or \"json\"", format)
+	}
+
+	return slog.New(handler), nil
+}
+
+func parseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q: expected debug, info, warn, or error", level)
+	}
+}
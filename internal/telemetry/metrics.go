@@ -0,0 +1,114 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus collectors exposed on --metrics-addr. It
+// satisfies the tunnel.Metrics and sshconn.Metrics interfaces so
+// those packages can record against it without importing Prometheus
+// themselves.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	connectionsTotal   *prometheus.CounterVec
+	activeConnections  prometheus.Gauge
+	bytesTransferred   *prometheus.CounterVec
+	dialErrorsTotal    *prometheus.CounterVec
+	sshReconnectsTotal prometheus.Counter
+	handshakeLatency   prometheus.Histogram
+}
+
+// NewMetrics registers the tunnel's collectors against a fresh
+// registry.
+func NewMetrics() *Metrics {
+	registry := prometheus.NewRegistry()
+	factory := promauto.With(registry)
+
+	return &Metrics{
+		registry: registry,
+		connectionsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "tunnel_connections_total",
+			Help: "Total number of connections accepted, by environment.",
+		}, []string{"env"}),
+		activeConnections: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "tunnel_active_connections",
+			Help: "Number of connections currently proxied.",
+		}),
+		bytesTransferred: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "tunnel_bytes_transferred",
+			Help: "Total bytes transferred, by direction (in/out).",
+		}, []string{"dir"}),
+		dialErrorsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "tunnel_dial_errors_total",
+			Help: "Total number of failed dials to a forward's destination, by environment.",
+		}, []string{"env"}),
+		sshReconnectsTotal: factory.NewCounter(prometheus.CounterOpts{
+			Name: "ssh_reconnects_total",
+			Help: "Total number of times the SSH connection was re-established after a failure.",
+		}),
+		handshakeLatency: factory.NewHistogram(prometheus.HistogramOpts{
+			Name:    "tunnel_ssh_handshake_latency_seconds",
+			Help:    "Latency of establishing the SSH connection to the bastion.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+}
+
+// ConnectionOpened records a newly accepted connection for env and
+// increments the active connection gauge.
+func (m *Metrics) ConnectionOpened(env string) {
+	m.connectionsTotal.WithLabelValues(env).Inc()
+	m.activeConnections.Inc()
+}
+
+// ConnectionClosed decrements the active connection gauge.
+func (m *Metrics) ConnectionClosed(env string) {
+	m.activeConnections.Dec()
+}
+
+// BytesTransferred records n bytes moved in direction dir ("in" or
+// "out").
+func (m *Metrics) BytesTransferred(dir string, n int64) {
+	m.bytesTransferred.WithLabelValues(dir).Add(float64(n))
+}
+
+// DialError records a failed dial to a forward's destination.
+func (m *Metrics) DialError(env string) {
+	m.dialErrorsTotal.WithLabelValues(env).Inc()
+}
+
+// SSHReconnected records a successful SSH reconnect.
+func (m *Metrics) SSHReconnected() {
+	m.sshReconnectsTotal.Inc()
+}
+
+// ObserveHandshakeLatency records how long the SSH handshake took.
+func (m *Metrics) ObserveHandshakeLatency(d time.Duration) {
+	m.handshakeLatency.Observe(d.Seconds())
+}
+
+// Serve starts an HTTP server exposing /metrics on addr until ctx is
+// canceled.
+func (m *Metrics) Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("serving metrics: %w", err)
+	}
+	return nil
+}
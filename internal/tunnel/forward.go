@@ -0,0 +1,101 @@
+package tunnel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Direction indicates which side of the SSH connection a forward is
+// listening on.
+type Direction int
+
+const (
+	// Local forwards bind a listener on the client and dial the remote
+	// host through the SSH connection (equivalent to ssh -L).
+	Local Direction = iota
+	// Remote forwards ask the SSH server to listen on its side and
+	// tunnel accepted connections back to a local destination
+	// (equivalent to ssh -R).
+	Remote
+)
+
+// ForwardSpec describes a single forward parsed from a spec string such
+// as "L:localhost:5602:opensearch.internal:443" or
+// "R:0.0.0.0:9200:kibana.internal:5601".
+type ForwardSpec struct {
+	Direction Direction
+	BindHost  string
+	BindPort  int
+	DestHost  string
+	DestPort  int
+}
+
+// ID returns a stable identifier for this forward, suitable for use with
+// Manager.RemoveForward.
+func (f ForwardSpec) ID() string {
+	dir := "L"
+	if f.Direction == Remote {
+		dir = "R"
+	}
+	return fmt.Sprintf("%s:%s:%d:%s:%d", dir, f.BindHost, f.BindPort, f.DestHost, f.DestPort)
+}
+
+func (f ForwardSpec) String() string {
+	return f.ID()
+}
+
+// ParseForward parses a single "L:bindHost:bindPort:destHost:destPort" or
+// "R:bindHost:bindPort:destHost:destPort" spec.
+func ParseForward(spec string) (ForwardSpec, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 5 {
+		return ForwardSpec{}, fmt.Errorf("invalid forward spec %q: expected L|R:bindHost:bindPort:destHost:destPort", spec)
+	}
+
+	var direction Direction
+	switch strings.ToUpper(parts[0]) {
+	case "L":
+		direction = Local
+	case "R":
+		direction = Remote
+	default:
+		return ForwardSpec{}, fmt.Errorf("invalid forward spec %q: direction must be L or R", spec)
+	}
+
+	bindPort, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return ForwardSpec{}, fmt.Errorf("invalid forward spec %q: bind port: %w", spec, err)
+	}
+
+	destPort, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return ForwardSpec{}, fmt.Errorf("invalid forward spec %q: dest port: %w", spec, err)
+	}
+
+	return ForwardSpec{
+		Direction: direction,
+		BindHost:  parts[1],
+		BindPort:  bindPort,
+		DestHost:  parts[3],
+		DestPort:  destPort,
+	}, nil
+}
+
+// ParseForwards parses a comma-separated list of forward specs, e.g.
+// "L:localhost:5602:opensearch.internal:443,R:0.0.0.0:9200:kibana.internal:5601".
+func ParseForwards(specs string) ([]ForwardSpec, error) {
+	var forwards []ForwardSpec
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		f, err := ParseForward(spec)
+		if err != nil {
+			return nil, err
+		}
+		forwards = append(forwards, f)
+	}
+	return forwards, nil
+}
@@ -0,0 +1,99 @@
+package tunnel
+
+import "testing"
+
+func TestParseForward(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    ForwardSpec
+		wantErr bool
+	}{
+		{
+			name: "local forward",
+			spec: "L:localhost:5602:opensearch.internal:443",
+			want: ForwardSpec{Direction: Local, BindHost: "localhost", BindPort: 5602, DestHost: "opensearch.internal", DestPort: 443},
+		},
+		{
+			name: "remote forward, lowercase direction",
+			spec: "r:0.0.0.0:9200:kibana.internal:5601",
+			want: ForwardSpec{Direction: Remote, BindHost: "0.0.0.0", BindPort: 9200, DestHost: "kibana.internal", DestPort: 5601},
+		},
+		{
+			name:    "too few fields",
+			spec:    "L:localhost:5602:opensearch.internal",
+			wantErr: true,
+		},
+		{
+			name:    "invalid direction",
+			spec:    "X:localhost:5602:opensearch.internal:443",
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric bind port",
+			spec:    "L:localhost:five:opensearch.internal:443",
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric dest port",
+			spec:    "L:localhost:5602:opensearch.internal:https",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseForward(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseForward(%q) = %+v, want error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseForward(%q) unexpected error: %v", tt.spec, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseForward(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseForwards(t *testing.T) {
+	specs := "L:localhost:5602:opensearch.internal:443, R:0.0.0.0:9200:kibana.internal:5601,"
+	got, err := ParseForwards(specs)
+	if err != nil {
+		t.Fatalf("ParseForwards(%q) unexpected error: %v", specs, err)
+	}
+
+	want := []ForwardSpec{
+		{Direction: Local, BindHost: "localhost", BindPort: 5602, DestHost: "opensearch.internal", DestPort: 443},
+		{Direction: Remote, BindHost: "0.0.0.0", BindPort: 9200, DestHost: "kibana.internal", DestPort: 5601},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ParseForwards(%q) = %+v, want %+v", specs, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ParseForwards(%q)[%d] = %+v, want %+v", specs, i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseForwardsInvalidSpec(t *testing.T) {
+	if _, err := ParseForwards("L:localhost:5602:opensearch.internal:443,garbage"); err == nil {
+		t.Fatal("ParseForwards with an invalid spec = nil error, want error")
+	}
+}
+
+func TestForwardSpecID(t *testing.T) {
+	f := ForwardSpec{Direction: Remote, BindHost: "0.0.0.0", BindPort: 9200, DestHost: "kibana.internal", DestPort: 5601}
+	want := "R:0.0.0.0:9200:kibana.internal:5601"
+	if got := f.ID(); got != want {
+		t.Fatalf("ID() = %q, want %q", got, want)
+	}
+	if got := f.String(); got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+}
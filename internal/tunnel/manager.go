@@ -0,0 +1,333 @@
+// Package tunnel implements a multiplexed local/remote port forwarder on
+// top of a single SSH connection.
+package tunnel
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+// Metrics is the subset of telemetry.Metrics the tunnel package needs,
+// kept as an interface here so tunnel doesn't have to import
+// Prometheus directly. A nil Metrics is valid and simply means no
+// metrics are recorded.
+type Metrics interface {
+	ConnectionOpened(env string)
+	ConnectionClosed(env string)
+	BytesTransferred(dir string, n int64)
+	DialError(env string)
+}
+
+// ClientSource supplies the current live SSH client. A plain *ssh.Client
+// never changes, but an *sshconn.Supervisor's client is replaced on
+// every reconnect, so Manager always asks for it fresh rather than
+// closing over a single client.
+type ClientSource interface {
+	Client() *ssh.Client
+}
+
+// staticClientSource adapts a fixed *ssh.Client to ClientSource for
+// callers that don't need reconnect support.
+type staticClientSource struct {
+	client *ssh.Client
+}
+
+func (s staticClientSource) Client() *ssh.Client { return s.client }
+
+// Manager owns an SSH client source and the set of local/remote
+// forwards multiplexed over it. It replaces the previous
+// listener-per-call approach in startTunnel/handleConnection, which
+// leaked the buffered conns channel and had no way to track or tear
+// down individual forwards.
+type Manager struct {
+	source  ClientSource
+	logger  *slog.Logger
+	metrics Metrics
+	env     string
+
+	mu       sync.Mutex
+	forwards map[string]*activeForward
+	closed   bool
+}
+
+// activeForward tracks the listener and live connections for one
+// ForwardSpec so Close and RemoveForward can shut them down cleanly.
+type activeForward struct {
+	spec     ForwardSpec
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	mu       sync.Mutex
+}
+
+// Option configures optional Manager behavior.
+type Option func(*Manager)
+
+// WithLogger attaches a structured logger; each connection's log lines
+// are annotated with a per-connection UUID so a slow request can be
+// traced end to end. Defaults to slog.Default() if not set.
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Manager) { m.logger = logger }
+}
+
+// WithMetrics attaches a Metrics sink labeled with env (e.g. the
+// environment name, or the bastion host for ad-hoc forwards).
+func WithMetrics(metrics Metrics, env string) Option {
+	return func(m *Manager) {
+		m.metrics = metrics
+		m.env = env
+	}
+}
+
+// NewManager creates a Manager bound to an already-dialed SSH client.
+// The Manager does not own the lifetime of client beyond Close, which
+// also closes client. For a connection that should survive transient
+// bastion restarts, use NewManagerFromSource with an
+// *sshconn.Supervisor instead.
+func NewManager(client *ssh.Client, opts ...Option) *Manager {
+	return NewManagerFromSource(staticClientSource{client: client}, opts...)
+}
+
+// NewManagerFromSource creates a Manager that re-resolves its SSH
+// client from source on every dial, so in-flight forwards survive the
+// source swapping in a new client after a reconnect.
+func NewManagerFromSource(source ClientSource, opts ...Option) *Manager {
+	m := &Manager{
+		source:   source,
+		logger:   slog.Default(),
+		forwards: make(map[string]*activeForward),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// AddForward starts listening for the given spec and begins proxying
+// connections. For a Local forward, it listens on BindHost:BindPort and
+// dials DestHost:DestPort through the SSH connection. For a Remote
+// forward, it asks the SSH server to listen on BindHost:BindPort and
+// dials DestHost:DestPort locally.
+func (m *Manager) AddForward(spec ForwardSpec) error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return fmt.Errorf("tunnel manager is closed")
+	}
+	if _, exists := m.forwards[spec.ID()]; exists {
+		m.mu.Unlock()
+		return fmt.Errorf("forward %s already registered", spec.ID())
+	}
+	m.mu.Unlock()
+
+	var (
+		listener net.Listener
+		err      error
+	)
+	bindAddr := net.JoinHostPort(spec.BindHost, strconv.Itoa(spec.BindPort))
+	switch spec.Direction {
+	case Local:
+		listener, err = net.Listen("tcp", bindAddr)
+	case Remote:
+		listener, err = m.source.Client().Listen("tcp", bindAddr)
+	default:
+		return fmt.Errorf("unknown forward direction for %s", spec.ID())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to listen for forward %s: %w", spec.ID(), err)
+	}
+
+	af := &activeForward{
+		spec:     spec,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	m.mu.Lock()
+	m.forwards[spec.ID()] = af
+	m.mu.Unlock()
+
+	go m.acceptLoop(af)
+	return nil
+}
+
+// acceptLoop accepts connections for a single forward and proxies each
+// one to its destination until the listener is closed.
+func (m *Manager) acceptLoop(af *activeForward) {
+	for {
+		conn, err := af.listener.Accept()
+		if err != nil {
+			return
+		}
+		go m.proxyConnection(af, conn)
+	}
+}
+
+// proxyConnection dials the forward's destination and copies data in
+// both directions until either side closes. Every connection gets a
+// UUID that's threaded through its log entries so a slow request can
+// be traced end-to-end.
+func (m *Manager) proxyConnection(af *activeForward, conn net.Conn) {
+	connID := uuid.NewString()
+	logger := m.logger.With("conn_id", connID, "forward", af.spec.ID())
+
+	if m.metrics != nil {
+		m.metrics.ConnectionOpened(m.env)
+		defer m.metrics.ConnectionClosed(m.env)
+	}
+
+	af.mu.Lock()
+	af.conns[conn] = struct{}{}
+	af.mu.Unlock()
+	defer func() {
+		af.mu.Lock()
+		delete(af.conns, conn)
+		af.mu.Unlock()
+		conn.Close()
+	}()
+
+	destAddr := net.JoinHostPort(af.spec.DestHost, strconv.Itoa(af.spec.DestPort))
+	logger.Debug("accepted connection", "dest", destAddr)
+
+	var (
+		dest net.Conn
+		err  error
+	)
+	switch af.spec.Direction {
+	case Local:
+		dest, err = m.source.Client().Dial("tcp", destAddr)
+	case Remote:
+		dest, err = net.Dial("tcp", destAddr)
+	}
+	if err != nil {
+		logger.Error("failed to dial destination", "dest", destAddr, "error", err)
+		if m.metrics != nil {
+			m.metrics.DialError(m.env)
+		}
+		return
+	}
+	defer dest.Close()
+
+	done := make(chan struct{}, 2)
+	go m.copyData(dest, conn, "out", connID, done)
+	go m.copyData(conn, dest, "in", connID, done)
+	<-done
+}
+
+// copyData copies from src to dst, counting bytes transferred in
+// direction dir if metrics are configured, and signals done when
+// finished.
+func (m *Manager) copyData(dst io.Writer, src io.Reader, dir, connID string, done chan<- struct{}) {
+	if m.metrics != nil {
+		dst = &countingWriter{w: dst, dir: dir, metrics: m.metrics}
+	}
+
+	_, err := io.Copy(dst, src)
+	if err != nil && err != io.EOF {
+		m.logger.With("conn_id", connID).Error("error copying data", "direction", dir, "error", err)
+	}
+	done <- struct{}{}
+}
+
+// countingWriter wraps an io.Writer and reports every write to
+// Metrics.BytesTransferred, used to instrument copyData without
+// threading counters through every call site.
+type countingWriter struct {
+	w       io.Writer
+	dir     string
+	metrics Metrics
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if n > 0 {
+		c.metrics.BytesTransferred(c.dir, int64(n))
+	}
+	return n, err
+}
+
+// HandleReconnect re-establishes every Remote forward's listener on
+// the new SSH client after a reconnect. It's meant to be passed as the
+// sshconn.Supervisor's OnReconnect callback. Local forwards need no
+// action here: their listener is a plain local net.Listener, and
+// proxyConnection already re-resolves the current client from source
+// on every dial.
+func (m *Manager) HandleReconnect(newClient *ssh.Client) {
+	m.mu.Lock()
+	forwards := make([]*activeForward, 0, len(m.forwards))
+	for _, af := range m.forwards {
+		forwards = append(forwards, af)
+	}
+	m.mu.Unlock()
+
+	for _, af := range forwards {
+		if af.spec.Direction != Remote {
+			continue
+		}
+
+		af.listener.Close()
+		bindAddr := fmt.Sprintf("%s:%d", af.spec.BindHost, af.spec.BindPort)
+		listener, err := newClient.Listen("tcp", bindAddr)
+		if err != nil {
+			m.logger.Error("failed to re-establish remote forward after reconnect", "forward", af.spec.ID(), "error", err)
+			continue
+		}
+
+		af.mu.Lock()
+		af.listener = listener
+		af.mu.Unlock()
+		go m.acceptLoop(af)
+	}
+}
+
+// RemoveForward stops and removes a previously added forward by its
+// ForwardSpec.ID(). Any in-flight connections on the forward are closed.
+func (m *Manager) RemoveForward(id string) error {
+	m.mu.Lock()
+	af, exists := m.forwards[id]
+	if !exists {
+		m.mu.Unlock()
+		return fmt.Errorf("forward %s is not registered", id)
+	}
+	delete(m.forwards, id)
+	m.mu.Unlock()
+
+	af.listener.Close()
+	af.mu.Lock()
+	for conn := range af.conns {
+		conn.Close()
+	}
+	af.mu.Unlock()
+	return nil
+}
+
+// Close tears down every registered forward and closes the underlying
+// SSH client.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	forwards := m.forwards
+	m.forwards = make(map[string]*activeForward)
+	m.mu.Unlock()
+
+	for _, af := range forwards {
+		af.listener.Close()
+		af.mu.Lock()
+		for conn := range af.conns {
+			conn.Close()
+		}
+		af.mu.Unlock()
+	}
+
+	return m.source.Client().Close()
+}
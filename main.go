@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net"
 	"os"
 	"os/exec"
@@ -16,6 +17,10 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/mauroartizzu/tf-go-tunnel-opensearch/internal/auth"
+	"github.com/mauroartizzu/tf-go-tunnel-opensearch/internal/proxy"
+	"github.com/mauroartizzu/tf-go-tunnel-opensearch/internal/sshconn"
+	"github.com/mauroartizzu/tf-go-tunnel-opensearch/internal/telemetry"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh"
@@ -29,7 +34,27 @@ type Config struct {
 }
 
 type Environment struct {
-	OpenSearchHost string `mapstructure:"opensearch_host" yaml:"opensearch_host"`
+	OpenSearchHost string     `mapstructure:"opensearch_host" yaml:"opensearch_host"`
+	Auth           AuthConfig `mapstructure:"auth" yaml:"auth,omitempty"`
+}
+
+// AuthConfig lets an environment override the top-level bastion/key
+// settings, e.g. to jump through a different bastion or use a
+// per-environment key.
+type AuthConfig struct {
+	BastionHost     string `mapstructure:"bastion_host" yaml:"bastion_host,omitempty"`
+	KeyPath         string `mapstructure:"key_path" yaml:"key_path,omitempty"`
+	InsecureHostKey bool   `mapstructure:"insecure_host_key" yaml:"insecure_host_key,omitempty"`
+
+	// BasicAuthUser/BasicAuthPassword, if set, are injected as an
+	// Authorization: Basic header on requests the local HTTPS proxy
+	// forwards to this environment's OpenSearch endpoint.
+	BasicAuthUser     string `mapstructure:"basic_auth_user" yaml:"basic_auth_user,omitempty"`
+	BasicAuthPassword string `mapstructure:"basic_auth_password" yaml:"basic_auth_password,omitempty"`
+
+	// SigV4Region, if set, signs proxied requests with AWS SigV4 for
+	// the "es" service instead of using basic auth.
+	SigV4Region string `mapstructure:"sigv4_region" yaml:"sigv4_region,omitempty"`
 }
 
 var (
@@ -57,6 +82,21 @@ func main() {
 	rootCmd.Flags().String("key", "", "SSH key path (e.g., ~/.ssh/key.pem)")
 	rootCmd.Flags().String("opensearch-staging", "", "Staging OpenSearch host")
 	rootCmd.Flags().String("opensearch-production", "", "Production OpenSearch host")
+	rootCmd.Flags().Bool("insecure-host-key", false, "Skip known_hosts verification (previous default behavior)")
+
+	rootCmd.PersistentFlags().String("log-level", "info", "Log level: debug, info, warn, or error")
+	rootCmd.PersistentFlags().String("log-format", "text", "Log format: text or json")
+	rootCmd.PersistentFlags().String("metrics-addr", "", "Address to serve Prometheus metrics on (e.g. :9100); disabled if empty")
+	rootCmd.PersistentFlags().Duration("keepalive-interval", 30*time.Second, "Interval between SSH keepalive requests")
+	rootCmd.PersistentFlags().Int("max-reconnect-attempts", 0, "Maximum SSH reconnect attempts after a keepalive failure (0 = unlimited)")
+
+	rootCmd.AddCommand(newServeCmd())
+	rootCmd.AddCommand(newClientCmd())
+	rootCmd.AddCommand(newPrintCACmd())
+	rootCmd.AddCommand(newStartCmd())
+	rootCmd.AddCommand(newStopCmd())
+	rootCmd.AddCommand(newStatusCmd())
+	rootCmd.AddCommand(newListCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -81,37 +121,76 @@ func runTunnel(cmd *cobra.Command, args []string) {
 			environment, getEnvironmentKeys(config.Environments))
 	}
 
-	// Extract username and host from bastion host string
-	parts := strings.Split(config.BastionHost, "@")
-	if len(parts) != 2 {
-		log.Fatalf("Invalid bastion host format. Expected format: username@hostname")
+	// Environments may override the bastion/key/host-key settings.
+	bastionHost := config.BastionHost
+	keyPath := config.KeyPath
+	if envConfig.Auth.BastionHost != "" {
+		bastionHost = envConfig.Auth.BastionHost
+	}
+	if envConfig.Auth.KeyPath != "" {
+		keyPath = envConfig.Auth.KeyPath
+	}
+	insecureHostKey, _ := cmd.Flags().GetBool("insecure-host-key")
+	insecureHostKey = insecureHostKey || envConfig.Auth.InsecureHostKey
+
+	// Extract username and host from bastion host string, resolving it
+	// as a ~/.ssh/config Host alias first.
+	username, hostname, proxyJump, err := resolveBastion(bastionHost, &keyPath)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
-	username, hostname := parts[0], parts[1]
 
 	// Prepare SSH client config
-	sshConfig, err := prepareSSHConfig(config.KeyPath, username)
+	sshConfig, err := prepareSSHConfig(keyPath, username, insecureHostKey)
 	if err != nil {
 		log.Fatalf("Failed to prepare SSH configuration: %v", err)
 	}
 
-	// Establish SSH connection
+	// Setup context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger, metrics := setupTelemetry(cmd, ctx)
+
+	// Establish SSH connection, supervised so a transient bastion
+	// restart reconnects instead of killing the tunnel.
 	fmt.Printf("Establishing SSH tunnel to %s environment...\n", environment)
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:22", hostname), sshConfig)
+	supervisor, err := dialSupervisor(cmd, hostname, proxyJump, insecureHostKey, sshConfig, logger, metrics)
 	if err != nil {
 		log.Fatalf("Failed to dial SSH server: %v", err)
 	}
-	defer client.Close()
 
-	// Setup context for graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	injectHeaders, err := headerInjectorFor(envConfig.Auth)
+	if err != nil {
+		log.Fatalf("Failed to configure OpenSearch auth: %v", err)
+	}
+
+	proxyServer, err := proxy.New(proxy.Options{
+		ListenAddr: fmt.Sprintf("localhost:%d", localPort),
+		TargetHost: envConfig.OpenSearchHost,
+		TargetPort: remotePort,
+		Dial: func(network, addr string) (net.Conn, error) {
+			return supervisor.Client().Dial(network, addr)
+		},
+		CertDir:       certDir(),
+		InjectHeaders: injectHeaders,
+		Logger:        logger,
+		Metrics:       metrics,
+		Env:           environment,
+	})
+	if err != nil {
+		log.Fatalf("Failed to start local HTTPS proxy: %v", err)
+	}
 
 	// Handle Ctrl+C
-	setupSignalHandler(cancel, client)
+	setupSignalHandler(cancel, proxyServer, supervisor)
 
-	// Start the SSH tunnel
-	err = startTunnel(ctx, client, envConfig.OpenSearchHost, localPort, remotePort)
-	if err != nil {
+	go func() {
+		if err := proxyServer.ListenAndServeTLS(); err != nil {
+			log.Printf("Proxy server error: %v", err)
+		}
+	}()
+	if err := waitForTunnelReady(localPort); err != nil {
 		log.Fatalf("Failed to start SSH tunnel: %v", err)
 	}
 
@@ -127,6 +206,100 @@ func runTunnel(cmd *cobra.Command, args []string) {
 	fmt.Println("SSH tunnel closed")
 }
 
+// dialSupervisor dials hostname:22 through an sshconn.Supervisor, so
+// the connection keeps itself alive with periodic keepalives and
+// reconnects with backoff if the transport fails. If proxyJump is set,
+// the connection is tunneled through that host instead of dialing
+// hostname directly, resolving it as a ~/.ssh/config Host alias the
+// same way the bastion itself is. Extra is passed through to
+// sshconn.NewSupervisor, e.g. so callers can register an OnReconnect
+// hook.
+func dialSupervisor(cmd *cobra.Command, hostname, proxyJump string, insecureHostKey bool, sshConfig *ssh.ClientConfig, logger *slog.Logger, metrics *telemetry.Metrics, extra ...sshconn.Option) (*sshconn.Supervisor, error) {
+	keepaliveInterval, _ := cmd.Flags().GetDuration("keepalive-interval")
+	maxReconnectAttempts, _ := cmd.Flags().GetInt("max-reconnect-attempts")
+
+	target := fmt.Sprintf("%s:22", hostname)
+
+	var jumpClient *ssh.Client
+	dial := func() (*ssh.Client, error) {
+		if jumpClient != nil {
+			jumpClient.Close()
+			jumpClient = nil
+		}
+
+		start := time.Now()
+		var client *ssh.Client
+		var err error
+		if proxyJump != "" {
+			client, jumpClient, err = dialThroughJump(proxyJump, target, sshConfig, insecureHostKey)
+		} else {
+			client, err = ssh.Dial("tcp", target, sshConfig)
+		}
+		if err == nil && metrics != nil {
+			metrics.ObserveHandshakeLatency(time.Since(start))
+		}
+		return client, err
+	}
+
+	opts := []sshconn.Option{
+		sshconn.WithKeepaliveInterval(keepaliveInterval),
+		sshconn.WithMaxReconnectAttempts(maxReconnectAttempts),
+		sshconn.WithLogger(logger),
+		sshconn.WithMetrics(metrics),
+	}
+	opts = append(opts, extra...)
+
+	return sshconn.NewSupervisor(dial, opts...)
+}
+
+// setupTelemetry builds the structured logger and metrics collector
+// shared by every command, installing logger as the slog default and,
+// if --metrics-addr is set, serving /metrics until ctx is canceled.
+func setupTelemetry(cmd *cobra.Command, ctx context.Context) (*slog.Logger, *telemetry.Metrics) {
+	level, _ := cmd.Flags().GetString("log-level")
+	format, _ := cmd.Flags().GetString("log-format")
+	logger, err := telemetry.NewLogger(level, format)
+	if err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	metrics := telemetry.NewMetrics()
+	if addr, _ := cmd.Flags().GetString("metrics-addr"); addr != "" {
+		go func() {
+			if err := metrics.Serve(ctx, addr); err != nil {
+				logger.Error("metrics server error", "error", err)
+			}
+		}()
+	}
+
+	return logger, metrics
+}
+
+// headerInjectorFor builds the proxy.HeaderInjector implied by an
+// environment's auth config, preferring SigV4 over basic auth when
+// both are somehow set. Returns a nil injector if neither is
+// configured.
+func headerInjectorFor(a AuthConfig) (proxy.HeaderInjector, error) {
+	if a.SigV4Region != "" {
+		return proxy.SigV4Injector("es", a.SigV4Region)
+	}
+	if a.BasicAuthUser != "" {
+		return proxy.BasicAuthInjector(a.BasicAuthUser, a.BasicAuthPassword), nil
+	}
+	return nil, nil
+}
+
+// certDir returns the directory where the local proxy's CA is
+// persisted, creating no side effects itself.
+func certDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".", ".config", "tf", "certs")
+	}
+	return filepath.Join(home, ".config", "tf", "certs")
+}
+
 // Gets configuration - loads existing or creates new
 func getConfig(cmd *cobra.Command) (*Config, error) {
 	// Check if config exists
@@ -169,110 +342,101 @@ func getEnvironment(cmd *cobra.Command, config *Config) string {
 	return environment
 }
 
-// Creates SSH config with key authentication
-func prepareSSHConfig(keyPath string, username string) (*ssh.ClientConfig, error) {
-	// Expand key path if it contains tilde
+// Creates SSH config, layering ssh-agent, key file and password
+// authentication, and verifying the server's host key against
+// ~/.ssh/known_hosts unless insecureHostKey is set.
+func prepareSSHConfig(keyPath string, username string, insecureHostKey bool) (*ssh.ClientConfig, error) {
 	expandedKeyPath := expandPath(keyPath)
 
-	// Read private key
-	key, err := os.ReadFile(expandedKeyPath)
+	home, err := os.UserHomeDir()
 	if err != nil {
-		return nil, fmt.Errorf("unable to read private key: %w", err)
+		return nil, fmt.Errorf("finding home directory: %w", err)
 	}
+	knownHostsPath := filepath.Join(home, ".ssh", "known_hosts")
 
-	// Parse private key
-	signer, err := ssh.ParsePrivateKey(key)
+	hostKeyCallback, err := auth.HostKeyCallback(knownHostsPath, insecureHostKey)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse private key: %w", err)
+		return nil, fmt.Errorf("preparing host key verification: %w", err)
 	}
 
 	return &ssh.ClientConfig{
-		User: username,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // Not secure for production
+		User:            username,
+		Auth:            auth.Methods(expandedKeyPath),
+		HostKeyCallback: hostKeyCallback,
 		Timeout:         15 * time.Second,
 	}, nil
 }
 
-// Starts the SSH tunnel
-func startTunnel(ctx context.Context, client *ssh.Client, remoteHost string, localPort, remotePort int) error {
-	// Start local listener
-	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", localPort))
+// resolveBastion splits a "user@host" bastion string into its username
+// and hostname, first resolving it as a ~/.ssh/config Host alias so
+// User/HostName/IdentityFile/ProxyJump can come from there. keyPath is
+// updated in place if the alias provides an IdentityFile and none was
+// already set.
+func resolveBastion(bastionHost string, keyPath *string) (username, hostname, proxyJump string, err error) {
+	resolved, err := auth.ResolveHost(bastionHost)
 	if err != nil {
-		return fmt.Errorf("failed to start local listener: %w", err)
+		return "", "", "", fmt.Errorf("resolving ~/.ssh/config: %w", err)
+	}
+	if resolved.IdentityFile != "" && *keyPath == "" {
+		*keyPath = resolved.IdentityFile
 	}
 
-	// Handle connections in a goroutine
-	go func() {
-		defer listener.Close()
-
-		// Channel to track active connections
-		conns := make(chan net.Conn, 10)
-		defer close(conns)
-
-		// Handle context cancellation
-		go func() {
-			<-ctx.Done()
-			listener.Close()
-			// Close any active connections
-			for conn := range conns {
-				conn.Close()
-			}
-		}()
-
-		// Accept connections
-		for {
-			conn, err := listener.Accept()
-			if err != nil {
-				// Check if listener was closed
-				if ctx.Err() != nil {
-					return
-				}
-				log.Printf("Error accepting connection: %v", err)
-				continue
-			}
-
-			// Handle the connection in a new goroutine
-			go handleConnection(client, conn, remoteHost, remotePort, conns)
+	if resolved.HostName != "" {
+		username = resolved.User
+		if username == "" {
+			username = bastionHost
 		}
-	}()
+		return username, resolved.HostName, resolved.ProxyJump, nil
+	}
 
-	// Wait for successful connection or timeout
-	return waitForTunnelReady(localPort)
+	parts := strings.Split(bastionHost, "@")
+	if len(parts) != 2 {
+		return "", "", "", fmt.Errorf("invalid bastion host format. Expected format: username@hostname")
+	}
+	return parts[0], parts[1], resolved.ProxyJump, nil
 }
 
-// Handles a single connection through the tunnel
-func handleConnection(client *ssh.Client, localConn net.Conn, remoteHost string, remotePort int, conns chan<- net.Conn) {
-	// Add to active connections
-	conns <- localConn
-	defer localConn.Close()
+// dialThroughJump dials jumpAlias over SSH (resolving it as a
+// ~/.ssh/config Host alias the same way a bastion is, building its auth
+// methods from the jump alias's own IdentityFile and reusing the
+// bastion's host key policy) and then tunnels an
+// SSH handshake with targetConfig through it to reach target
+// ("host:port"). It returns both the resulting client to target and
+// the jump client it's tunneled through, so the caller can close the
+// jump client on the next redial or on shutdown. Only a single
+// ProxyJump hop is supported; comma-separated multi-hop chains are
+// not.
+func dialThroughJump(jumpAlias, target string, targetConfig *ssh.ClientConfig, insecureHostKey bool) (client, jumpClient *ssh.Client, err error) {
+	jumpKeyPath := ""
+	jumpUser, jumpHost, _, err := resolveBastion(jumpAlias, &jumpKeyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolving ProxyJump host %q: %w", jumpAlias, err)
+	}
 
-	// Dial remote host through SSH tunnel
-	remoteConn, err := client.Dial("tcp", fmt.Sprintf("%s:%d", remoteHost, remotePort))
+	jumpConfig, err := prepareSSHConfig(jumpKeyPath, jumpUser, insecureHostKey)
 	if err != nil {
-		log.Printf("Failed to connect to remote host: %v", err)
-		return
+		return nil, nil, fmt.Errorf("preparing ProxyJump SSH configuration: %w", err)
 	}
-	defer remoteConn.Close()
 
-	// Copy data in both directions
-	done := make(chan bool, 2)
-	go copyData(localConn, remoteConn, done)
-	go copyData(remoteConn, localConn, done)
+	jumpClient, err = ssh.Dial("tcp", fmt.Sprintf("%s:22", jumpHost), jumpConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("dialing ProxyJump host %s: %w", jumpHost, err)
+	}
 
-	// Wait for either connection to close
-	<-done
-}
+	conn, err := jumpClient.Dial("tcp", target)
+	if err != nil {
+		jumpClient.Close()
+		return nil, nil, fmt.Errorf("dialing %s through ProxyJump: %w", target, err)
+	}
 
-// Copies data between connections
-func copyData(dst, src net.Conn, done chan<- bool) {
-	_, err := io.Copy(dst, src)
-	if err != nil && err != io.EOF {
-		log.Printf("Error copying data: %v", err)
+	ncc, chans, reqs, err := ssh.NewClientConn(conn, target, targetConfig)
+	if err != nil {
+		conn.Close()
+		jumpClient.Close()
+		return nil, nil, fmt.Errorf("SSH handshake with %s through ProxyJump: %w", target, err)
 	}
-	done <- true
+
+	return ssh.NewClient(ncc, chans, reqs), jumpClient, nil
 }
 
 // Waits for tunnel to be ready
@@ -291,15 +455,18 @@ func waitForTunnelReady(port int) error {
 	return nil
 }
 
-// Sets up signal handler for graceful shutdown
-func setupSignalHandler(cancel context.CancelFunc, client *ssh.Client) {
+// Sets up signal handler for graceful shutdown, closing every closer
+// (tunnel manager, proxy server, SSH client, ...) in the order given.
+func setupSignalHandler(cancel context.CancelFunc, closers ...io.Closer) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
 		fmt.Println("\nClosing SSH tunnel...")
 		cancel()
-		client.Close()
+		for _, closer := range closers {
+			closer.Close()
+		}
 	}()
 }
 
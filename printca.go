@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/mauroartizzu/tf-go-tunnel-opensearch/internal/proxy"
+	"github.com/spf13/cobra"
+)
+
+// newPrintCACmd builds the "print-ca" subcommand, which emits the local
+// proxy's CA certificate so it can be trusted once in the OS/browser
+// trust store, generating it first if it doesn't exist yet.
+func newPrintCACmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "print-ca",
+		Short: "Print the local HTTPS proxy's CA certificate (generating it if needed)",
+		Run: func(cmd *cobra.Command, args []string) {
+			ca, err := proxy.LoadOrCreateCA(certDir())
+			if err != nil {
+				log.Fatalf("Failed to load or create CA: %v", err)
+			}
+			fmt.Print(string(ca.CertPEM))
+			_ = os.Stdout.Sync()
+		},
+	}
+}
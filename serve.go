@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/mauroartizzu/tf-go-tunnel-opensearch/internal/sshconn"
+	"github.com/mauroartizzu/tf-go-tunnel-opensearch/internal/tunnel"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+)
+
+// newServeCmd builds the "serve" subcommand, which multiplexes one or
+// more local/remote forwards over a single SSH connection to a bastion
+// host. Unlike the default root command, it is not tied to the
+// OpenSearch dashboard use case and does not open a browser.
+func newServeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Establish one or more multiplexed tunnels over a single SSH connection",
+		Run:   runServe,
+	}
+
+	cmd.Flags().String("bastion", "", "Bastion host (e.g., ubuntu@12.34.56.78)")
+	cmd.Flags().String("key", "", "SSH key path (e.g., ~/.ssh/key.pem)")
+	cmd.Flags().StringSlice("forward", nil, "Forward spec, e.g. L:localhost:5602:opensearch.internal:443 (repeatable, or comma-separated)")
+	cmd.Flags().Bool("insecure-host-key", false, "Skip known_hosts verification (previous default behavior)")
+
+	return cmd
+}
+
+// newClientCmd is an alias for "serve" kept for symmetry with chisel's
+// client/server naming; both establish the outbound SSH connection and
+// multiplex forwards over it.
+func newClientCmd() *cobra.Command {
+	cmd := newServeCmd()
+	cmd.Use = "client"
+	cmd.Short = "Alias of \"serve\": establish multiplexed tunnels over a single SSH connection"
+	return cmd
+}
+
+func runServe(cmd *cobra.Command, args []string) {
+	config, err := getConfig(cmd)
+	if err != nil {
+		log.Fatalf("Error with configuration: %v", err)
+	}
+
+	bastion, _ := cmd.Flags().GetString("bastion")
+	if bastion == "" {
+		bastion = config.BastionHost
+	}
+
+	keyPath, _ := cmd.Flags().GetString("key")
+	if keyPath == "" {
+		keyPath = config.KeyPath
+	}
+
+	username, hostname, proxyJump, err := resolveBastion(bastion, &keyPath)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	specs, _ := cmd.Flags().GetStringSlice("forward")
+	if len(specs) == 0 {
+		log.Fatalf("At least one --forward spec is required, e.g. --forward L:localhost:5602:opensearch.internal:443")
+	}
+
+	var forwards []tunnel.ForwardSpec
+	for _, spec := range specs {
+		parsed, err := tunnel.ParseForwards(spec)
+		if err != nil {
+			log.Fatalf("Invalid forward: %v", err)
+		}
+		forwards = append(forwards, parsed...)
+	}
+
+	insecureHostKey, _ := cmd.Flags().GetBool("insecure-host-key")
+	sshConfig, err := prepareSSHConfig(keyPath, username, insecureHostKey)
+	if err != nil {
+		log.Fatalf("Failed to prepare SSH configuration: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger, metrics := setupTelemetry(cmd, ctx)
+
+	fmt.Printf("Establishing SSH connection to %s...\n", hostname)
+	var mgr *tunnel.Manager
+	supervisor, err := dialSupervisor(cmd, hostname, proxyJump, insecureHostKey, sshConfig, logger, metrics,
+		sshconn.WithOnReconnect(func(c *ssh.Client) {
+			if mgr != nil {
+				mgr.HandleReconnect(c)
+			}
+		}),
+	)
+	if err != nil {
+		log.Fatalf("Failed to dial SSH server: %v", err)
+	}
+
+	mgr = tunnel.NewManagerFromSource(supervisor, tunnel.WithLogger(logger), tunnel.WithMetrics(metrics, bastion))
+
+	setupSignalHandler(cancel, mgr, supervisor)
+
+	for _, f := range forwards {
+		if err := mgr.AddForward(f); err != nil {
+			log.Fatalf("Failed to add forward %s: %v", f.ID(), err)
+		}
+		fmt.Printf("Forwarding %s\n", f.ID())
+	}
+
+	fmt.Println("Tunnel established. Press Ctrl+C to close.")
+	<-ctx.Done()
+}